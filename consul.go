@@ -1,7 +1,11 @@
 package goffkv_consul
 
 import (
+    "context"
+    "net/http"
+    "net/url"
     "strings"
+    "sync"
     "time"
     "fmt"
     goffkv "github.com/offscale/goffkv"
@@ -12,50 +16,203 @@ const (
     ttl = "10s"
 )
 
-type consulClient struct {
+// TLSConfig carries the TLS material used to reach a TLS-secured Consul
+// agent. It mirrors consulapi.TLSConfig, which is only reachable through
+// consulapi.Config.
+type TLSConfig struct {
+    CAFile string
+    CertFile string
+    KeyFile string
+    InsecureSkipVerify bool
+    ServerName string
+}
+
+// SessionOptions configures the Consul session that backs leased
+// (ephemeral) keys created via Create(..., lease=true). Unset fields fall
+// back to the driver's previous hard-coded defaults (a 10s TTL, a
+// nanosecond LockDelay, and SessionBehaviorDelete).
+type SessionOptions struct {
+    TTL string
+    LockDelay time.Duration
+    Behavior string
+    Name string
+    Node string
+    Checks []string
+    ServiceChecks []consulapi.ServiceCheck
+}
+
+// Config configures a Client beyond the bare address that New
+// accepts. All fields are optional; the zero value behaves like
+// consulapi.DefaultConfig() with only Address overridden.
+type Config struct {
+    Address string
+    Scheme string
+    Token string
+    TokenFile string
+    Datacenter string
+    Namespace string
+    Partition string
+    TLSConfig TLSConfig
+    HttpClient *http.Client
+    SessionOptions SessionOptions
+}
+
+// WatchCtx is the context-aware counterpart of goffkv.Watch, returned by
+// the Ctx-suffixed methods (ExistsCtx, GetCtx, ChildrenCtx, Tree) so that
+// callers can bound a long-poll with a context instead of blocking
+// indefinitely. goffkv.Watch itself is a plain func() and cannot carry a
+// context or report an error.
+type WatchCtx func(context.Context) error
+
+func toPlainWatch(w WatchCtx) goffkv.Watch {
+    if w == nil {
+        return nil
+    }
+    return func() {
+        _ = w(context.Background())
+    }
+}
+
+// Client is the consul-backed implementation of goffkv.Client. The
+// concrete type is exported (unlike its unexported fields) so that
+// callers needing CreateMany, SetMany or Tree — which goffkv.Client does
+// not declare — can type-assert the goffkv.Client returned by New or
+// NewWithConfig back to *Client.
+type Client struct {
     consul *consulapi.Client
     kv *consulapi.KV
     txn *consulapi.Txn
     prefixSegments []string
+    token string
+    datacenter string
+    namespace string
+    sessionOptions SessionOptions
+
+    // sessionMu guards sessionId/sessionRenewDoneCh so that concurrent
+    // leased Create/Commit calls don't race to create two sessions, and so
+    // the renewal goroutine can safely notice its session was invalidated.
+    sessionMu sync.Mutex
     sessionId string
     sessionRenewDoneCh chan struct{}
 }
 
-func makeQueryOptions() *consulapi.QueryOptions {
-    return &consulapi.QueryOptions{
+func (c *Client) makeQueryOptions(ctx context.Context) *consulapi.QueryOptions {
+    opts := &consulapi.QueryOptions{
         RequireConsistent: true,
+        Token: c.token,
+        Datacenter: c.datacenter,
+        Namespace: c.namespace,
     }
+    return opts.WithContext(ctx)
 }
 
-func (c *consulClient) assemblePath(segments []string) string {
+func (c *Client) makeWriteOptions(ctx context.Context) *consulapi.WriteOptions {
+    opts := &consulapi.WriteOptions{
+        Token: c.token,
+        Datacenter: c.datacenter,
+        Namespace: c.namespace,
+    }
+    return opts.WithContext(ctx)
+}
+
+func (c *Client) assemblePath(segments []string) string {
     parts := []string{}
     parts = append(parts, c.prefixSegments...)
     parts = append(parts, segments...)
     return strings.Join(parts, "/")
 }
 
+// New opens a Client against the Consul agent at address, storing
+// keys under prefix. It is a thin wrapper around NewWithConfig for callers
+// that do not need ACL tokens, non-default datacenters/namespaces, or TLS.
 func New(address string, prefix string) (goffkv.Client, error) {
+    return NewWithConfig(Config{Address: address}, prefix)
+}
+
+// NewWithConfig opens a Client using cfg, storing keys under prefix.
+func NewWithConfig(cfg Config, prefix string) (goffkv.Client, error) {
     prefixSegments, err := goffkv.DisassemblePath(prefix)
     if err != nil {
         return nil, err
     }
+
     config := consulapi.DefaultConfig()
-    config.Address = address
+    config.Address = cfg.Address
+    if cfg.Scheme != "" {
+        config.Scheme = cfg.Scheme
+    }
+    if cfg.TokenFile != "" {
+        config.TokenFile = cfg.TokenFile
+    }
+    if cfg.Partition != "" {
+        config.Partition = cfg.Partition
+    }
+    if cfg.HttpClient != nil {
+        config.HttpClient = cfg.HttpClient
+    }
+    if cfg.TLSConfig != (TLSConfig{}) {
+        if cfg.TLSConfig.ServerName != "" {
+            config.TLSConfig.Address = cfg.TLSConfig.ServerName
+        }
+        if cfg.TLSConfig.CAFile != "" {
+            config.TLSConfig.CAFile = cfg.TLSConfig.CAFile
+        }
+        if cfg.TLSConfig.CertFile != "" {
+            config.TLSConfig.CertFile = cfg.TLSConfig.CertFile
+        }
+        if cfg.TLSConfig.KeyFile != "" {
+            config.TLSConfig.KeyFile = cfg.TLSConfig.KeyFile
+        }
+        if cfg.TLSConfig.InsecureSkipVerify {
+            config.TLSConfig.InsecureSkipVerify = true
+        }
+    }
+
     consul, err := consulapi.NewClient(config)
     if err != nil {
         return nil, err
     }
-    return &consulClient{
+    return &Client{
         consul: consul,
         kv: consul.KV(),
         txn: consul.Txn(),
         prefixSegments: prefixSegments,
+        token: cfg.Token,
+        datacenter: cfg.Datacenter,
+        namespace: cfg.Namespace,
+        sessionOptions: cfg.SessionOptions,
         sessionId: "",
         sessionRenewDoneCh: nil,
     }, nil
 }
 
-func (c *consulClient) maybeGetParent(segments []string) []*consulapi.TxnOp {
+// newFromConnString builds a Client from a connection string of the
+// form "[token@]host:port[?dc=...&ns=...&partition=...&insecure=true]", for
+// goffkv.RegisterClient schemes that must pack the whole Config into a
+// single address string.
+func newFromConnString(address string, prefix string) (goffkv.Client, error) {
+    u, err := url.Parse("consul://" + address)
+    if err != nil {
+        return nil, err
+    }
+
+    cfg := Config{Address: u.Host}
+    if u.User != nil {
+        cfg.Token = u.User.Username()
+    }
+
+    q := u.Query()
+    cfg.Datacenter = q.Get("dc")
+    cfg.Namespace = q.Get("ns")
+    cfg.Partition = q.Get("partition")
+    if q.Get("insecure") == "true" {
+        cfg.TLSConfig.InsecureSkipVerify = true
+    }
+
+    return NewWithConfig(cfg, prefix)
+}
+
+func (c *Client) maybeGetParent(segments []string) []*consulapi.TxnOp {
     if len(segments) > 1 {
         return []*consulapi.TxnOp{
             &consulapi.TxnOp{
@@ -69,31 +226,77 @@ func (c *consulClient) maybeGetParent(segments []string) []*consulapi.TxnOp {
     return []*consulapi.TxnOp{}
 }
 
-func (c *consulClient) getOrCreateSessionId() (string, error) {
+func (c *Client) getOrCreateSessionId(ctx context.Context) (string, error) {
+    c.sessionMu.Lock()
+    defer c.sessionMu.Unlock()
+
     if c.sessionId != "" {
         return c.sessionId, nil
     }
 
+    sessionTTL := c.sessionOptions.TTL
+    if sessionTTL == "" {
+        sessionTTL = ttl
+    }
+    lockDelay := c.sessionOptions.LockDelay
+    if lockDelay == 0 {
+        lockDelay = time.Nanosecond
+    }
+    behavior := c.sessionOptions.Behavior
+    if behavior == "" {
+        behavior = consulapi.SessionBehaviorDelete
+    }
+
     session := c.consul.Session()
 
     se := consulapi.SessionEntry{
-        LockDelay: time.Nanosecond,
-        Behavior: consulapi.SessionBehaviorDelete,
-        TTL: ttl,
+        Name: c.sessionOptions.Name,
+        Node: c.sessionOptions.Node,
+        Checks: c.sessionOptions.Checks,
+        ServiceChecks: c.sessionOptions.ServiceChecks,
+        LockDelay: lockDelay,
+        Behavior: behavior,
+        TTL: sessionTTL,
     }
-    sessionId, _, err := session.Create(&se, nil)
+    sessionId, _, err := session.Create(&se, c.makeWriteOptions(ctx))
     if err != nil {
         return "", err
     }
 
-    c.sessionRenewDoneCh = make(chan struct{})
-    go session.RenewPeriodic(ttl, sessionId, nil, c.sessionRenewDoneCh)
-
+    doneCh := make(chan struct{})
     c.sessionId = sessionId
+    c.sessionRenewDoneCh = doneCh
+    go c.renewSession(session, sessionTTL, sessionId, doneCh)
+
     return sessionId, nil
 }
 
-func (c *consulClient) Create(key string, value []byte, lease bool) (goffkv.Version, error) {
+// renewSession keeps sessionId alive until doneCh is closed (by Close) or
+// Consul invalidates the session server-side, at which point RenewPeriodic
+// returns. In the latter case the session is gone, so it is cleared here —
+// as long as nothing has already replaced it — letting the next leased op
+// transparently create a fresh one.
+func (c *Client) renewSession(session *consulapi.Session, sessionTTL string, sessionId string, doneCh chan struct{}) {
+    _ = session.RenewPeriodic(sessionTTL, sessionId, c.makeWriteOptions(context.Background()), doneCh)
+
+    c.sessionMu.Lock()
+    defer c.sessionMu.Unlock()
+    if c.sessionRenewDoneCh == doneCh {
+        c.sessionId = ""
+        c.sessionRenewDoneCh = nil
+    }
+}
+
+// Create implements goffkv.Client by delegating to CreateCtx with a
+// background context.
+func (c *Client) Create(key string, value []byte, lease bool) (goffkv.Version, error) {
+    return c.CreateCtx(context.Background(), key, value, lease)
+}
+
+// CreateCtx is Create's context-aware counterpart; ctx bounds the
+// underlying Consul transaction (and, for leased entries, the session
+// lookup/creation it may trigger).
+func (c *Client) CreateCtx(ctx context.Context, key string, value []byte, lease bool) (goffkv.Version, error) {
     segments, err := goffkv.DisassembleKey(key)
     if err != nil {
         return 0, err
@@ -109,7 +312,7 @@ func (c *consulClient) Create(key string, value []byte, lease bool) (goffkv.Vers
     })
 
     if lease {
-        sessionId, err := c.getOrCreateSessionId()
+        sessionId, err := c.getOrCreateSessionId(ctx)
         if err != nil {
             return 0, err
         }
@@ -131,7 +334,7 @@ func (c *consulClient) Create(key string, value []byte, lease bool) (goffkv.Vers
         })
     }
 
-    ok, ret, _, err := c.txn.Txn(ops, makeQueryOptions())
+    ok, ret, _, err := c.txn.Txn(ops, c.makeQueryOptions(ctx))
     if err != nil {
         return 0, err
     }
@@ -157,7 +360,14 @@ func (c *consulClient) Create(key string, value []byte, lease bool) (goffkv.Vers
     }
 }
 
-func (c *consulClient) Set(key string, value []byte) (goffkv.Version, error) {
+// Set implements goffkv.Client by delegating to SetCtx with a background
+// context.
+func (c *Client) Set(key string, value []byte) (goffkv.Version, error) {
+    return c.SetCtx(context.Background(), key, value)
+}
+
+// SetCtx is Set's context-aware counterpart.
+func (c *Client) SetCtx(ctx context.Context, key string, value []byte) (goffkv.Version, error) {
     segments, err := goffkv.DisassembleKey(key)
     if err != nil {
         return 0, err
@@ -172,7 +382,7 @@ func (c *consulClient) Set(key string, value []byte) (goffkv.Version, error) {
         },
     })
 
-    ok, ret, _, err := c.txn.Txn(ops, makeQueryOptions())
+    ok, ret, _, err := c.txn.Txn(ops, c.makeQueryOptions(ctx))
     if err != nil {
         return 0, err
     }
@@ -186,9 +396,16 @@ func (c *consulClient) Set(key string, value []byte) (goffkv.Version, error) {
     }
 }
 
-func (c *consulClient) Cas(key string, value []byte, ver goffkv.Version) (goffkv.Version, error) {
+// Cas implements goffkv.Client by delegating to CasCtx with a background
+// context.
+func (c *Client) Cas(key string, value []byte, ver goffkv.Version) (goffkv.Version, error) {
+    return c.CasCtx(context.Background(), key, value, ver)
+}
+
+// CasCtx is Cas's context-aware counterpart.
+func (c *Client) CasCtx(ctx context.Context, key string, value []byte, ver goffkv.Version) (goffkv.Version, error) {
     if ver == 0 {
-        resultVer, err := c.Create(key, value, false)
+        resultVer, err := c.CreateCtx(ctx, key, value, false)
         if err == nil {
             return resultVer, nil
         }
@@ -220,7 +437,7 @@ func (c *consulClient) Cas(key string, value []byte, ver goffkv.Version) (goffkv
         },
     }
 
-    ok, ret, _, err := c.txn.Txn(ops, makeQueryOptions())
+    ok, ret, _, err := c.txn.Txn(ops, c.makeQueryOptions(ctx))
     if err != nil {
         return 0, err
     }
@@ -238,7 +455,14 @@ func (c *consulClient) Cas(key string, value []byte, ver goffkv.Version) (goffkv
     }
 }
 
-func (c *consulClient) Erase(key string, ver goffkv.Version) error {
+// Erase implements goffkv.Client by delegating to EraseCtx with a
+// background context.
+func (c *Client) Erase(key string, ver goffkv.Version) error {
+    return c.EraseCtx(context.Background(), key, ver)
+}
+
+// EraseCtx is Erase's context-aware counterpart.
+func (c *Client) EraseCtx(ctx context.Context, key string, ver goffkv.Version) error {
     segments, err := goffkv.DisassembleKey(key)
     if err != nil {
         return err
@@ -278,7 +502,7 @@ func (c *consulClient) Erase(key string, ver goffkv.Version) error {
         },
     })
 
-    ok, ret, _, err := c.txn.Txn(ops, makeQueryOptions())
+    ok, ret, _, err := c.txn.Txn(ops, c.makeQueryOptions(ctx))
     if err != nil {
         return err
     }
@@ -293,7 +517,15 @@ func (c *consulClient) Erase(key string, ver goffkv.Version) error {
     return nil
 }
 
-func (c *consulClient) Exists(key string, watch bool) (goffkv.Version, goffkv.Watch, error) {
+// Exists implements goffkv.Client by delegating to ExistsCtx with a
+// background context.
+func (c *Client) Exists(key string, watch bool) (goffkv.Version, goffkv.Watch, error) {
+    ver, w, err := c.ExistsCtx(context.Background(), key, watch)
+    return ver, toPlainWatch(w), err
+}
+
+// ExistsCtx is Exists's context-aware counterpart.
+func (c *Client) ExistsCtx(ctx context.Context, key string, watch bool) (goffkv.Version, WatchCtx, error) {
     segments, err := goffkv.DisassembleKey(key)
     if err != nil {
         return 0, nil, err
@@ -301,7 +533,7 @@ func (c *consulClient) Exists(key string, watch bool) (goffkv.Version, goffkv.Wa
 
     frozenPath := c.assemblePath(segments)
 
-    kv, _, err := c.kv.Get(frozenPath, makeQueryOptions())
+    kv, _, err := c.kv.Get(frozenPath, c.makeQueryOptions(ctx))
     if err != nil {
         return 0, nil, err
     }
@@ -311,19 +543,28 @@ func (c *consulClient) Exists(key string, watch bool) (goffkv.Version, goffkv.Wa
     }
 
     resultVer := kv.ModifyIndex
-    var resultWatch goffkv.Watch
+    var resultWatch WatchCtx
 
     if watch {
-        resultWatch = func() {
-            opts := makeQueryOptions()
+        resultWatch = func(watchCtx context.Context) error {
+            opts := c.makeQueryOptions(watchCtx)
             opts.WaitIndex = resultVer
-            _, _, _ = c.kv.Get(frozenPath, opts)
+            _, _, err := c.kv.Get(frozenPath, opts)
+            return err
         }
     }
     return resultVer, resultWatch, nil
 }
 
-func (c *consulClient) Get(key string, watch bool) (goffkv.Version, []byte, goffkv.Watch, error) {
+// Get implements goffkv.Client by delegating to GetCtx with a background
+// context.
+func (c *Client) Get(key string, watch bool) (goffkv.Version, []byte, goffkv.Watch, error) {
+    ver, value, w, err := c.GetCtx(context.Background(), key, watch)
+    return ver, value, toPlainWatch(w), err
+}
+
+// GetCtx is Get's context-aware counterpart.
+func (c *Client) GetCtx(ctx context.Context, key string, watch bool) (goffkv.Version, []byte, WatchCtx, error) {
     segments, err := goffkv.DisassembleKey(key)
     if err != nil {
         return 0, nil, nil, err
@@ -331,7 +572,7 @@ func (c *consulClient) Get(key string, watch bool) (goffkv.Version, []byte, goff
 
     frozenPath := c.assemblePath(segments)
 
-    kv, _, err := c.kv.Get(frozenPath, makeQueryOptions())
+    kv, _, err := c.kv.Get(frozenPath, c.makeQueryOptions(ctx))
     if err != nil {
         return 0, nil, nil, err
     }
@@ -341,13 +582,14 @@ func (c *consulClient) Get(key string, watch bool) (goffkv.Version, []byte, goff
     }
 
     resultVer := kv.ModifyIndex
-    var resultWatch goffkv.Watch
+    var resultWatch WatchCtx
 
     if watch {
-        resultWatch = func() {
-            opts := makeQueryOptions()
+        resultWatch = func(watchCtx context.Context) error {
+            opts := c.makeQueryOptions(watchCtx)
             opts.WaitIndex = resultVer
-            _, _, _ = c.kv.Get(frozenPath, opts)
+            _, _, err := c.kv.Get(frozenPath, opts)
+            return err
         }
     }
     return resultVer, kv.Value, resultWatch, nil
@@ -366,7 +608,15 @@ func detachChild(path string, nPrefix int, nGlobalPrefix int) string {
     }
 }
 
-func (c *consulClient) Children(key string, watch bool) ([]string, goffkv.Watch, error) {
+// Children implements goffkv.Client by delegating to ChildrenCtx with a
+// background context.
+func (c *Client) Children(key string, watch bool) ([]string, goffkv.Watch, error) {
+    children, w, err := c.ChildrenCtx(context.Background(), key, watch)
+    return children, toPlainWatch(w), err
+}
+
+// ChildrenCtx is Children's context-aware counterpart.
+func (c *Client) ChildrenCtx(ctx context.Context, key string, watch bool) ([]string, WatchCtx, error) {
     segments, err := goffkv.DisassembleKey(key)
     if err != nil {
         return nil, nil, err
@@ -390,7 +640,7 @@ func (c *consulClient) Children(key string, watch bool) ([]string, goffkv.Watch,
         },
     }
 
-    ok, ret, _, err := c.txn.Txn(ops, makeQueryOptions())
+    ok, ret, _, err := c.txn.Txn(ops, c.makeQueryOptions(ctx))
     if err != nil {
         return nil, nil, err
     }
@@ -410,7 +660,7 @@ func (c *consulClient) Children(key string, watch bool) ([]string, goffkv.Watch,
         }
     }
 
-    var resultWatch goffkv.Watch
+    var resultWatch WatchCtx
     if watch {
         var ver uint64
         for _, result := range results {
@@ -419,16 +669,133 @@ func (c *consulClient) Children(key string, watch bool) ([]string, goffkv.Watch,
                 ver = curVer
             }
         }
-        resultWatch = func() {
-            opts := makeQueryOptions()
+        resultWatch = func(watchCtx context.Context) error {
+            opts := c.makeQueryOptions(watchCtx)
             opts.WaitIndex = ver
-            _, _, _ = c.kv.List(frozenPath, opts)
+            _, _, err := c.kv.List(frozenPath, opts)
+            return err
         }
     }
 
     return children, resultWatch, nil
 }
 
+// Entry is a single key/value pair returned by Tree, carrying its path
+// relative to the queried key plus its Consul ModifyIndex. goffkv has no
+// equivalent type, since it has no multi-entry retrieval call.
+type Entry struct {
+    Key string
+    Value []byte
+    Ver goffkv.Version
+}
+
+// TreeOptions controls Tree's retrieval of a key's subtree.
+type TreeOptions struct {
+    // Recursive includes entries nested below direct children. When
+    // false, Tree behaves like Children but also returns values/versions.
+    Recursive bool
+    // Separator delimits path segments when computing depth for MaxDepth.
+    // Defaults to "/".
+    Separator string
+    // MaxDepth bounds how many Separator-delimited levels below key are
+    // returned when Recursive is set. Zero means unlimited.
+    MaxDepth int
+    // Limit caps the number of entries returned. Zero means unlimited.
+    Limit int
+    // ContinueAfter, if set, skips entries up to and including this
+    // relative key, for paging through a prefix larger than Limit.
+    ContinueAfter string
+}
+
+// Tree retrieves key's subtree in a single round trip, optionally
+// recursively and/or paginated via opts. Unlike Children, each result
+// carries its value and ModifyIndex rather than just its relative name.
+func (c *Client) Tree(ctx context.Context, key string, opts TreeOptions) ([]Entry, WatchCtx, error) {
+    segments, err := goffkv.DisassembleKey(key)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    frozenPath := c.assemblePath(segments)
+    frozenPrefix := frozenPath + "/"
+
+    separator := opts.Separator
+    if separator == "" {
+        separator = "/"
+    }
+
+    ops := []*consulapi.TxnOp{
+        &consulapi.TxnOp{
+            KV: &consulapi.KVTxnOp{
+                Verb: consulapi.KVGetTree,
+                Key: frozenPrefix,
+            },
+        },
+        &consulapi.TxnOp{
+            KV: &consulapi.KVTxnOp{
+                Verb: consulapi.KVGet,
+                Key: frozenPath,
+            },
+        },
+    }
+
+    ok, ret, _, err := c.txn.Txn(ops, c.makeQueryOptions(ctx))
+    if err != nil {
+        return nil, nil, err
+    }
+
+    if !ok {
+        return nil, nil, goffkv.OpErrNoEntry
+    }
+
+    results := ret.Results
+
+    var maxVer uint64
+    entries := []Entry{}
+    for i := 0; i < len(results) - 1; i++ {
+        pair := results[i].KV
+        if pair.ModifyIndex > maxVer {
+            maxVer = pair.ModifyIndex
+        }
+
+        relKey := strings.TrimPrefix(pair.Key, frozenPrefix)
+        if relKey == "" {
+            continue
+        }
+
+        depth := strings.Count(relKey, separator) + 1
+        if !opts.Recursive && depth > 1 {
+            continue
+        }
+        if opts.Recursive && opts.MaxDepth > 0 && depth > opts.MaxDepth {
+            continue
+        }
+        if opts.ContinueAfter != "" && relKey <= opts.ContinueAfter {
+            continue
+        }
+
+        entries = append(entries, Entry{
+            Key: relKey,
+            Value: pair.Value,
+            Ver: pair.ModifyIndex,
+        })
+
+        if opts.Limit > 0 && len(entries) == opts.Limit {
+            break
+        }
+    }
+
+    ver := maxVer
+    resultWatch := WatchCtx(func(watchCtx context.Context) error {
+        watchOpts := c.makeQueryOptions(watchCtx)
+        watchOpts.WaitIndex = ver
+        _, _, err := c.kv.List(frozenPath, watchOpts)
+        return err
+    })
+
+    return entries, resultWatch, nil
+}
+
 type resultKind int
 const (
     rkCreate resultKind = iota
@@ -445,7 +812,14 @@ func toUserOpIndex(boundaries []int, op int) int {
     return -1
 }
 
-func (c *consulClient) Commit(txn goffkv.Txn) ([]goffkv.TxnOpResult, error) {
+// Commit implements goffkv.Client by delegating to CommitCtx with a
+// background context.
+func (c *Client) Commit(txn goffkv.Txn) ([]goffkv.TxnOpResult, error) {
+    return c.CommitCtx(context.Background(), txn)
+}
+
+// CommitCtx is Commit's context-aware counterpart.
+func (c *Client) CommitCtx(ctx context.Context, txn goffkv.Txn) ([]goffkv.TxnOpResult, error) {
     ops := []*consulapi.TxnOp{}
     boundaries := []int{}
     rks := []resultKind{}
@@ -500,7 +874,7 @@ func (c *consulClient) Commit(txn goffkv.Txn) ([]goffkv.TxnOpResult, error) {
             // KVCheckNotExists does not produce any results
 
             if op.Lease {
-                sessionId, err := c.getOrCreateSessionId()
+                sessionId, err := c.getOrCreateSessionId(ctx)
                 if err != nil {
                     return nil, err
                 }
@@ -570,7 +944,7 @@ func (c *consulClient) Commit(txn goffkv.Txn) ([]goffkv.TxnOpResult, error) {
         boundaries = append(boundaries, len(ops) - 1)
     }
 
-    ok, ret, _, err := c.txn.Txn(ops, makeQueryOptions())
+    ok, ret, _, err := c.txn.Txn(ops, c.makeQueryOptions(ctx))
     if err != nil {
         return nil, err
     }
@@ -599,12 +973,320 @@ func (c *consulClient) Commit(txn goffkv.Txn) ([]goffkv.TxnOpResult, error) {
     }
 }
 
-func (c *consulClient) Close() {
-    if c.sessionRenewDoneCh != nil {
-        close(c.sessionRenewDoneCh)
+// maxOpsPerTxn is the number of KV operations Consul accepts in a single
+// transaction (see the /v1/txn API docs).
+const maxOpsPerTxn = 64
+
+// CreateEntry is a single entry passed to CreateMany. goffkv has no
+// equivalent type, since it has no batch-create call.
+type CreateEntry struct {
+    Key string
+    Value []byte
+    Lease bool
+}
+
+// SetEntry is a single entry passed to SetMany. goffkv has no equivalent
+// type, since it has no batch-set call.
+type SetEntry struct {
+    Key string
+    Value []byte
+}
+
+// BatchError reports the entries that could not be created/set by
+// CreateMany/SetMany. Every chunk is its own Consul transaction and is
+// therefore all-or-nothing: when a chunk fails, every entry in that chunk
+// gets an entry in Failed, not just the one whose op Consul rejected —
+// the rest carry a wrapping error naming the entry that caused the
+// rollback.
+type BatchError struct {
+    Failed map[int]error
+}
+
+func (e BatchError) Error() string {
+    return fmt.Sprintf("goffkv-consul: %d of the batch's entries failed", len(e.Failed))
+}
+
+// CreateMany creates entries in chunks of at most maxOpsPerTxn Consul KV
+// ops per transaction, emitting the parent-existence check once per unique
+// parent segment within a chunk rather than once per entry. Leased entries
+// in the same call share a single session. A failure in one chunk does not
+// prevent the remaining chunks from being attempted; see BatchError.
+func (c *Client) CreateMany(ctx context.Context, entries []CreateEntry) ([]goffkv.Version, error) {
+    results := make([]goffkv.Version, len(entries))
+    failed := map[int]error{}
+
+    needsLease := false
+    for _, entry := range entries {
+        if entry.Lease {
+            needsLease = true
+            break
+        }
+    }
+    var sessionId string
+    if needsLease {
+        id, err := c.getOrCreateSessionId(ctx)
+        if err != nil {
+            return nil, err
+        }
+        sessionId = id
+    }
+
+    i := 0
+    for i < len(entries) {
+        ops := []*consulapi.TxnOp{}
+        rks := []resultKind{}
+        boundaries := []int{}
+        createEntryIndex := []int{}
+        checkNotExistsOpIndex := []int{}
+        parentPaths := []string{}
+        seenParents := map[string]bool{}
+        parentOpIndex := map[string]int{}
+        chunkStart := i
+
+        for i < len(entries) {
+            segments, err := goffkv.DisassembleKey(entries[i].Key)
+            if err != nil {
+                return nil, err
+            }
+
+            parentPath := ""
+            needParentOp := false
+            if len(segments) > 1 {
+                parentPath = c.assemblePath(segments[:len(segments) - 1])
+                needParentOp = !seenParents[parentPath]
+            }
+
+            entryOpCount := 2
+            if needParentOp {
+                entryOpCount++
+            }
+            if len(ops) > 0 && len(ops) + entryOpCount > maxOpsPerTxn {
+                break
+            }
+
+            if needParentOp {
+                ops = append(ops, &consulapi.TxnOp{
+                    KV: &consulapi.KVTxnOp{
+                        Verb: consulapi.KVGet,
+                        Key: parentPath,
+                    },
+                })
+                rks = append(rks, rkAux)
+                seenParents[parentPath] = true
+                parentOpIndex[parentPath] = len(ops) - 1
+            }
+
+            ops = append(ops, &consulapi.TxnOp{
+                KV: &consulapi.KVTxnOp{
+                    Verb: consulapi.KVCheckNotExists,
+                    Key: c.assemblePath(segments),
+                },
+            })
+            // KVCheckNotExists does not produce any results
+            checkNotExistsOpIndex = append(checkNotExistsOpIndex, len(ops) - 1)
+            parentPaths = append(parentPaths, parentPath)
+
+            if entries[i].Lease {
+                ops = append(ops, &consulapi.TxnOp{
+                    KV: &consulapi.KVTxnOp{
+                        Verb: consulapi.KVLock,
+                        Key: c.assemblePath(segments),
+                        Value: entries[i].Value,
+                        Session: sessionId,
+                    },
+                })
+            } else {
+                ops = append(ops, &consulapi.TxnOp{
+                    KV: &consulapi.KVTxnOp{
+                        Verb: consulapi.KVSet,
+                        Key: c.assemblePath(segments),
+                        Value: entries[i].Value,
+                    },
+                })
+            }
+            rks = append(rks, rkCreate)
+            createEntryIndex = append(createEntryIndex, i - chunkStart)
+
+            boundaries = append(boundaries, len(ops) - 1)
+            i++
+        }
+
+        ok, ret, _, err := c.txn.Txn(ops, c.makeQueryOptions(ctx))
+        if err != nil {
+            return nil, err
+        }
+
+        if ok {
+            // rks (and so txnResults, which Consul omits an entry from for
+            // every KVCheckNotExists) is a different, compacted index space
+            // from boundaries/ops, so it cannot be run through
+            // toUserOpIndex. Walk the two parallel, already-compacted
+            // slices together instead.
+            txnResults := ret.Results
+            ci := 0
+            for j := 0; j < len(txnResults); j++ {
+                if rks[j] == rkCreate {
+                    results[chunkStart + createEntryIndex[ci]] = txnResults[j].KV.ModifyIndex
+                    ci++
+                }
+            }
+        } else {
+            firstError := ret.Errors[0]
+            userIndex := toUserOpIndex(boundaries, firstError.OpIndex)
+            if userIndex < 0 {
+                panic("txn failed on non-existing op")
+            }
+
+            var culpritErr error
+            switch {
+            case parentPaths[userIndex] != "" && firstError.OpIndex == parentOpIndex[parentPaths[userIndex]]:
+                culpritErr = goffkv.OpErrNoEntry
+            case firstError.OpIndex == checkNotExistsOpIndex[userIndex]:
+                culpritErr = goffkv.OpErrEntryExists
+            default:
+                culpritErr = fmt.Errorf(
+                    "unexpected txn failure: %q at operation %d",
+                    firstError.What, firstError.OpIndex)
+            }
+
+            // The transaction is all-or-nothing: every entry in this
+            // chunk, not just the culprit, failed to apply.
+            for idx := chunkStart; idx < i; idx++ {
+                if idx == chunkStart + userIndex {
+                    failed[idx] = culpritErr
+                } else {
+                    failed[idx] = fmt.Errorf(
+                        "chunk rolled back because entry %d failed: %w",
+                        chunkStart + userIndex, culpritErr)
+                }
+            }
+        }
+    }
+
+    if len(failed) > 0 {
+        return results, BatchError{failed}
+    }
+    return results, nil
+}
+
+// SetMany is CreateMany's counterpart for unconditional writes: it packs
+// entries into the same chunked, parent-deduplicated transactions, but
+// uses KVSet (with no existence check) in place of KVCheckNotExists+KVLock.
+func (c *Client) SetMany(ctx context.Context, entries []SetEntry) ([]goffkv.Version, error) {
+    results := make([]goffkv.Version, len(entries))
+    failed := map[int]error{}
+
+    i := 0
+    for i < len(entries) {
+        ops := []*consulapi.TxnOp{}
+        rks := []resultKind{}
+        boundaries := []int{}
+        seenParents := map[string]bool{}
+        chunkStart := i
+
+        for i < len(entries) {
+            segments, err := goffkv.DisassembleKey(entries[i].Key)
+            if err != nil {
+                return nil, err
+            }
+
+            parentPath := ""
+            needParentOp := false
+            if len(segments) > 1 {
+                parentPath = c.assemblePath(segments[:len(segments) - 1])
+                needParentOp = !seenParents[parentPath]
+            }
+
+            entryOpCount := 1
+            if needParentOp {
+                entryOpCount++
+            }
+            if len(ops) > 0 && len(ops) + entryOpCount > maxOpsPerTxn {
+                break
+            }
+
+            if needParentOp {
+                ops = append(ops, &consulapi.TxnOp{
+                    KV: &consulapi.KVTxnOp{
+                        Verb: consulapi.KVGet,
+                        Key: parentPath,
+                    },
+                })
+                rks = append(rks, rkAux)
+                seenParents[parentPath] = true
+            }
+
+            ops = append(ops, &consulapi.TxnOp{
+                KV: &consulapi.KVTxnOp{
+                    Verb: consulapi.KVSet,
+                    Key: c.assemblePath(segments),
+                    Value: entries[i].Value,
+                },
+            })
+            rks = append(rks, rkSet)
+
+            boundaries = append(boundaries, len(ops) - 1)
+            i++
+        }
+
+        ok, ret, _, err := c.txn.Txn(ops, c.makeQueryOptions(ctx))
+        if err != nil {
+            return nil, err
+        }
+
+        if ok {
+            txnResults := ret.Results
+            for j := 0; j < len(txnResults); j++ {
+                if rks[j] == rkSet {
+                    results[chunkStart + toUserOpIndex(boundaries, j)] = txnResults[j].KV.ModifyIndex
+                }
+            }
+        } else {
+            firstError := ret.Errors[0]
+            userIndex := toUserOpIndex(boundaries, firstError.OpIndex)
+            if userIndex < 0 {
+                panic("txn failed on non-existing op")
+            }
+            // As with Set, the only op that can fail here is the parent
+            // existence check.
+
+            // The transaction is all-or-nothing: every entry in this
+            // chunk, not just the culprit, failed to apply.
+            for idx := chunkStart; idx < i; idx++ {
+                if idx == chunkStart + userIndex {
+                    failed[idx] = goffkv.OpErrNoEntry
+                } else {
+                    failed[idx] = fmt.Errorf(
+                        "chunk rolled back because entry %d failed: %w",
+                        chunkStart + userIndex, goffkv.OpErrNoEntry)
+                }
+            }
+        }
+    }
+
+    if len(failed) > 0 {
+        return results, BatchError{failed}
+    }
+    return results, nil
+}
+
+func (c *Client) Close() {
+    c.sessionMu.Lock()
+    sessionId := c.sessionId
+    doneCh := c.sessionRenewDoneCh
+    c.sessionId = ""
+    c.sessionRenewDoneCh = nil
+    c.sessionMu.Unlock()
+
+    if doneCh != nil {
+        close(doneCh)
+    }
+    if sessionId != "" {
+        _, _ = c.consul.Session().Destroy(sessionId, c.makeWriteOptions(context.Background()))
     }
 }
 
 func init() {
     goffkv.RegisterClient("consul", New)
+    goffkv.RegisterClient("consul-auth", newFromConnString)
 }